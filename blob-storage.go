@@ -0,0 +1,156 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gocloud.dev/blob"
+
+	_ "gocloud.dev/blob/azureblob"
+	_ "gocloud.dev/blob/gcsblob"
+	_ "gocloud.dev/blob/s3blob"
+)
+
+// bucketSchemes are the gocloud.dev/blob URL schemes PopulateImagesFromBucket accepts.
+var bucketSchemes = map[string]bool{
+	"gs":     true,
+	"s3":     true,
+	"azblob": true,
+}
+
+// IsBucketURL reports whether inputPath names an object in a cloud storage bucket
+// (gs://, s3:// or azblob://) rather than a path on the local filesystem.
+func IsBucketURL(inputPath string) bool {
+	scheme, _, found := strings.Cut(inputPath, "://")
+	return found && bucketSchemes[scheme]
+}
+
+//---------------------------------------------------------------------------------------
+
+// PopulateImagesFromBucket lists objects in a cloud storage bucket and populates a list
+// of images in preparation for OCR, the same way PopulateImages does for the local
+// filesystem. bucketURL is a gocloud.dev/blob URL whose path names the prefix/pattern to
+// match keys against, e.g. "gs://my-bucket/inbox/**/*.png".
+//
+// Matched objects are streamed to a local temp file so the OCR providers, which read
+// from a local path via ImageInfo.SourcePath, don't need to change; ImageInfo.Filename
+// keeps the fully-qualified bucket URL. The caller must call the returned cleanup func,
+// once OCR has finished with the whole batch, to remove those temp files.
+func (files *ImageFiles) PopulateImagesFromBucket(bucketURL string) (func(), error) {
+	cleanupAll := func() {}
+
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return cleanupAll, fmt.Errorf("Invalid bucket URL: %w", err)
+	}
+	if !bucketSchemes[u.Scheme] {
+		return cleanupAll, fmt.Errorf("Unsupported bucket URL scheme: %s", u.Scheme)
+	}
+
+	keyPattern := strings.TrimPrefix(u.Path, "/")
+	base, _ := doublestar.SplitPattern(keyPattern)
+	if base == "." {
+		base = ""
+	}
+
+	ctx := context.Background()
+	bucket, err := blob.OpenBucket(ctx, fmt.Sprintf("%s://%s", u.Scheme, u.Host))
+	if err != nil {
+		return cleanupAll, fmt.Errorf("Failed to open bucket: %w", err)
+	}
+	defer bucket.Close()
+
+	var tempFiles []string
+	cleanupAll = func() {
+		for _, tempFile := range tempFiles {
+			os.Remove(tempFile)
+		}
+	}
+
+	iter := bucket.List(&blob.ListOptions{Prefix: base})
+	for {
+		object, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanupAll()
+			return func() {}, fmt.Errorf("Failed to list bucket objects: %w", err)
+		}
+		if object.IsDir {
+			continue
+		}
+
+		matched, err := doublestar.Match(keyPattern, object.Key)
+		if err != nil {
+			cleanupAll()
+			return func() {}, fmt.Errorf("Malformed bucket URL pattern: %w", err)
+		}
+
+		mimeType, supported := mimeTypes[strings.ToLower(filepath.Ext(object.Key))]
+		if !matched || !supported {
+			continue
+		}
+
+		tempFile, err := downloadToTempFile(ctx, bucket, object.Key)
+		if err != nil {
+			cleanupAll()
+			return func() {}, fmt.Errorf("Failed to download %s: %w", object.Key, err)
+		}
+		tempFiles = append(tempFiles, tempFile)
+
+		files.Images = append(files.Images, ImageInfo{
+			Filename:   fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, object.Key),
+			Size:       object.Size,
+			MimeType:   mimeType,
+			sourcePath: tempFile,
+		})
+	}
+
+	return cleanupAll, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// downloadToTempFile streams key's contents from bucket to a new local temp file,
+// returning its path.
+func downloadToTempFile(ctx context.Context, bucket *blob.Bucket, key string) (string, error) {
+	reader, err := bucket.NewReader(ctx, key, nil)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	tempFile, err := os.CreateTemp("", "ocr-runner-blob-*"+filepath.Ext(key))
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, reader); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
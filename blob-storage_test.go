@@ -0,0 +1,40 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func Test_IsBucketURL(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		inputPath string
+		want      bool
+	}{
+		{name: "gs", inputPath: "gs://my-bucket/inbox/**/*.png", want: true},
+		{name: "s3", inputPath: "s3://my-bucket/inbox/*.jpg", want: true},
+		{name: "azblob", inputPath: "azblob://my-container/inbox/*.tif", want: true},
+		{name: "unsupported scheme", inputPath: "https://example.com/a.jpg", want: false},
+		{name: "local path", inputPath: "./examples/**/*.jpg", want: false},
+		{name: "local path with colon-like content", inputPath: "examples/file:name.jpg", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBucketURL(tt.inputPath); got != tt.want {
+				t.Errorf("IsBucketURL(%q) = %v, want %v", tt.inputPath, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// checkpointEntry records, for a single input image, the content hash it was
+// processed with and where its JSON line lives in the output file so that a
+// subsequent run can skip redoing completed work.
+type checkpointEntry struct {
+	Hash     string `json:"hash"`
+	Complete bool   `json:"complete"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+}
+
+// checkpointState is the sidecar "<output>.state.json" file that backs -resume.
+type checkpointState struct {
+	Entries map[string]checkpointEntry `json:"entries"`
+
+	path string
+	mu   sync.Mutex
+}
+
+//---------------------------------------------------------------------------------------
+
+// checkpointPath returns the sidecar state file path for a given output file.
+func checkpointPath(outputFile string) string {
+	return outputFile + ".state.json"
+}
+
+//---------------------------------------------------------------------------------------
+
+// loadCheckpointState reads the sidecar state file for outputFile, returning an
+// empty state if it does not yet exist.
+func loadCheckpointState(outputFile string) (*checkpointState, error) {
+	state := &checkpointState{Entries: make(map[string]checkpointEntry), path: checkpointPath(outputFile)}
+
+	data, err := os.ReadFile(state.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("Failed to read checkpoint state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Failed to parse checkpoint state file: %w", err)
+	}
+
+	return state, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// save persists the checkpoint state back to its sidecar file.
+func (state *checkpointState) save() error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal checkpoint state: %w", err)
+	}
+
+	if err := os.WriteFile(state.path, data, 0644); err != nil {
+		return fmt.Errorf("Failed to write checkpoint state file: %w", err)
+	}
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// markComplete records that filename was processed with content hash and its
+// JSON line occupies [offset, offset+length) in the (new) output file.
+func (state *checkpointState) markComplete(filename, hash string, offset, length int64) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.Entries[filename] = checkpointEntry{Hash: hash, Complete: true, Offset: offset, Length: length}
+}
+
+//---------------------------------------------------------------------------------------
+
+// reusable reports whether filename was previously completed with the given
+// content hash, returning its prior checkpoint entry if so.
+func (state *checkpointState) reusable(filename, hash string) (checkpointEntry, bool) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	entry, ok := state.Entries[filename]
+	if !ok || !entry.Complete || entry.Hash != hash {
+		return checkpointEntry{}, false
+	}
+
+	return entry, true
+}
+
+//---------------------------------------------------------------------------------------
+
+// fileSHA256 hashes the contents of filename, used to detect whether an
+// input image has changed since a prior checkpointed run.
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
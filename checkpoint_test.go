@@ -0,0 +1,84 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func Test_checkpointState_reusable(t *testing.T) {
+
+	state := &checkpointState{Entries: make(map[string]checkpointEntry)}
+	state.markComplete("a.jpg", "hash-a", 0, 10)
+	state.Entries["b.jpg"] = checkpointEntry{Hash: "hash-b", Complete: false, Offset: 10, Length: 10}
+
+	type args struct {
+		filename string
+		hash     string
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantOK   bool
+		wantHash string
+	}{
+		{
+			name:     "complete and matching hash",
+			args:     args{filename: "a.jpg", hash: "hash-a"},
+			wantOK:   true,
+			wantHash: "hash-a",
+		},
+		{
+			name:   "complete but stale hash",
+			args:   args{filename: "a.jpg", hash: "hash-changed"},
+			wantOK: false,
+		},
+		{
+			name:   "not complete",
+			args:   args{filename: "b.jpg", hash: "hash-b"},
+			wantOK: false,
+		},
+		{
+			name:   "unknown filename",
+			args:   args{filename: "c.jpg", hash: "hash-c"},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := state.reusable(tt.args.filename, tt.args.hash)
+			if ok != tt.wantOK {
+				t.Errorf("reusable() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && entry.Hash != tt.wantHash {
+				t.Errorf("reusable() entry.Hash = %v, want %v", entry.Hash, tt.wantHash)
+			}
+		})
+	}
+}
+
+func Test_checkpointState_markComplete(t *testing.T) {
+
+	state := &checkpointState{Entries: make(map[string]checkpointEntry)}
+
+	state.markComplete("a.jpg", "hash-a", 0, 10)
+	state.markComplete("a.jpg", "hash-a2", 10, 20)
+
+	entry, ok := state.Entries["a.jpg"]
+	if !ok {
+		t.Fatalf("markComplete() did not record an entry for a.jpg")
+	}
+	if !entry.Complete || entry.Hash != "hash-a2" || entry.Offset != 10 || entry.Length != 20 {
+		t.Errorf("markComplete() entry = %+v, want latest values to overwrite the prior entry", entry)
+	}
+}
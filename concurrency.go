@@ -0,0 +1,413 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DetectOptions controls how ImageFiles.DetectImageText executes and writes
+// its results. It has grown alongside the CLI flags that populate it rather
+// than DetectImageText taking an ever longer list of positional arguments.
+type DetectOptions struct {
+	OutputFull      bool
+	OutputFormat    string
+	Registry        string
+	Concurrency     int
+	QPS             float64
+	CompletionOrder bool
+	Resume          bool
+	Force           bool
+}
+
+// detectResult is the outcome of running the OCR provider against a single
+// image, keyed by its position in ImageFiles.Images so output can be
+// serialized in input order even though the work happens concurrently.
+type detectResult struct {
+	index    int
+	filename string
+	jsonData []byte
+	err      error
+}
+
+// detectError pairs a failed image's filename with the error that failed it.
+type detectError struct {
+	filename string
+	err      error
+}
+
+func (e detectError) Error() string {
+	return fmt.Sprintf("%s: %v", e.filename, e.err)
+}
+
+// detectErrors aggregates every per-image failure from a DetectImageText run so
+// callers can see what went wrong, not just how many images failed.
+type detectErrors []detectError
+
+func (errs detectErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return fmt.Sprintf("%d of the images failed OCR: %s", len(errs), strings.Join(messages, "; "))
+}
+
+const (
+	maxOCRAttempts = 4
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 8 * time.Second
+)
+
+//---------------------------------------------------------------------------------------
+
+// Iterate through the image file list and call the configured OCR provider to detect the
+// text, fanning the work out across a worker pool bounded by opts.Concurrency and, if
+// opts.QPS is set, a token-bucket rate limiter. Per-image errors are accumulated and
+// reported once processing of the whole batch has finished, rather than aborting it.
+//
+// Results are written to the OutputSink selected by opts.OutputFormat (see
+// NewOutputSink). When opts.Resume is set and the sink supports it, images whose
+// content hash matches a prior completed checkpoint entry have their previous JSON
+// line carried forward unchanged instead of being reprocessed; opts.Force reprocesses
+// every image regardless of the checkpoint.
+func (files *ImageFiles) DetectImageText(outputFile string, provider OCRProvider, opts DetectOptions) error {
+
+	// Snapshot the previous output file's bytes before NewOutputSink creates (and for
+	// the jsonl/gzip sinks, truncates) the file at the same path - resolveCheckpoint
+	// needs the prior run's JSON lines to splice reusable entries from, and they would
+	// otherwise already be gone by the time it reads outputFile itself.
+	var priorOutput []byte
+	if opts.Resume && !opts.Force {
+		data, err := os.ReadFile(outputFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to read previous output file: %w", err)
+		}
+		priorOutput = data
+	}
+
+	sink, err := NewOutputSink(outputFile, opts.OutputFormat)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	if opts.Resume && !sink.SupportsResume() {
+		logger.Warn().Str("OutputFormat", opts.OutputFormat).Msg("-resume is not supported for this output format, ignoring")
+		opts.Resume = false
+	}
+
+	var state *checkpointState
+	hashes := make([]string, len(files.Images))
+	reuse := make([]*reuseEntry, len(files.Images))
+
+	// Checkpoint keys are taken before OCR runs, since a provider may set Page (as
+	// CallDocumentAI does for a single-page document) and so change CheckpointKey.
+	keys := make([]string, len(files.Images))
+	for i := range files.Images {
+		keys[i] = files.Images[i].CheckpointKey()
+	}
+	if sink.SupportsResume() {
+		state, err = loadCheckpointState(outputFile)
+		if err != nil {
+			return err
+		}
+
+		hashes, reuse, err = files.resolveCheckpoint(priorOutput, state, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var limiter *rate.Limiter
+	if opts.QPS > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.QPS), 1)
+	}
+
+	ctx := context.Background()
+	jobs := make(chan int)
+	results := make(chan detectResult)
+
+	var workers sync.WaitGroup
+	workers.Add(concurrency)
+	for worker := 0; worker < concurrency; worker++ {
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				results <- files.processImage(ctx, i, provider, limiter, opts)
+			}
+		}()
+	}
+
+	go func() {
+		for i := range files.Images {
+			if reuse[i] == nil {
+				jobs <- i
+			}
+		}
+		close(jobs)
+	}()
+
+	var reused sync.WaitGroup
+	reused.Add(1)
+	go func() {
+		defer reused.Done()
+		for i, entry := range reuse {
+			if entry == nil {
+				continue
+			}
+			results <- detectResult{index: i, jsonData: entry.data}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		reused.Wait()
+		close(results)
+	}()
+
+	errs := writeDetectResults(files, sink, results, opts.CompletionOrder, func(index int, offset, length int64) {
+		if state != nil {
+			state.markComplete(keys[index], hashes[index], offset, length)
+		}
+	})
+
+	if state != nil {
+		if err := state.save(); err != nil {
+			logger.Error().Err(err).Msg("Failed to save checkpoint state")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// reuseEntry pairs a prior checkpoint entry with the JSON line it refers to,
+// read once up front from the previous output file.
+type reuseEntry struct {
+	data []byte
+}
+
+// resolveCheckpoint hashes every image's content and, when resuming, decides which
+// images can reuse their previously checkpointed JSON line instead of being
+// reprocessed. priorOutput is the previous run's output file contents, read by the
+// caller before the new output sink truncated it; it is nil when not resuming. It
+// returns the per-image content hash alongside a parallel slice of reusable entries
+// (nil where the image must be (re)processed).
+func (files *ImageFiles) resolveCheckpoint(priorOutput []byte, state *checkpointState, opts DetectOptions) ([]string, []*reuseEntry, error) {
+	hashes := make([]string, len(files.Images))
+	reuse := make([]*reuseEntry, len(files.Images))
+
+	for i := range files.Images {
+		hash, err := fileSHA256(files.Images[i].SourcePath())
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to hash %s: %w", files.Images[i].SourcePath(), err)
+		}
+		hashes[i] = hash
+
+		if !opts.Resume || opts.Force {
+			continue
+		}
+
+		entry, ok := state.reusable(files.Images[i].CheckpointKey(), hash)
+		if !ok || entry.Offset+entry.Length > int64(len(priorOutput)) {
+			continue
+		}
+
+		logger.Info().Str("Filename", files.Images[i].Filename).Msg("... Skipping, already completed")
+		reuse[i] = &reuseEntry{data: priorOutput[entry.Offset : entry.Offset+entry.Length]}
+	}
+
+	return hashes, reuse, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// processImage runs the OCR provider against a single image (retrying
+// transient gRPC errors with backoff), marshals the result to JSON and, if
+// a registry was configured, pushes it as an OCI artifact.
+func (files *ImageFiles) processImage(ctx context.Context, index int, provider OCRProvider, limiter *rate.Limiter, opts DetectOptions) detectResult {
+	info := &files.Images[index]
+
+	logger.Info().Msg("Image:")
+	logger.Info().Str("Filename", info.Filename).Msg(indent)
+	logger.Info().Int64("Size", info.Size).Msg(indent)
+	logger.Info().Str("MimeType", info.MimeType).Msg(indent)
+
+	// Page records already populated by the Document AI batch path carry their text
+	// with them, so they don't need a further OCR provider call
+	if info.Page == 0 || len(info.Text) == 0 {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return detectResult{index: index, filename: info.Filename, err: err}
+			}
+		}
+
+		if err := recognizeWithRetry(ctx, provider, info); err != nil {
+			logger.Error().Err(err).Str("Filename", info.Filename).Msg("OCR provider request failed")
+			return detectResult{index: index, filename: info.Filename, err: err}
+		}
+	}
+
+	var jsonData []byte
+	var err error
+	if opts.OutputFull {
+		jsonData, err = info.GetFullJSON()
+	} else {
+		jsonData, err = info.GetCompactJSON()
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("Filename", info.Filename).Msg("Failed to marshal json data")
+		return detectResult{index: index, filename: info.Filename, err: err}
+	}
+
+	if len(opts.Registry) > 0 {
+		if err := info.PushOCIArtifact(opts.Registry, jsonData); err != nil {
+			logger.Error().Err(err).Str("Filename", info.Filename).Msg("Failed to push OCI artifact")
+			return detectResult{index: index, filename: info.Filename, err: err}
+		}
+	}
+
+	return detectResult{index: index, filename: info.Filename, jsonData: jsonData}
+}
+
+//---------------------------------------------------------------------------------------
+
+// recognizeWithRetry calls provider.Recognize, retrying with exponential
+// backoff when the failure is a transient gRPC error (Unavailable or
+// ResourceExhausted).
+func recognizeWithRetry(ctx context.Context, provider OCRProvider, info *ImageInfo) error {
+	backoff := initialBackoff
+
+	var err error
+	for attempt := 1; attempt <= maxOCRAttempts; attempt++ {
+		err = provider.Recognize(ctx, info)
+		if err == nil {
+			return nil
+		}
+
+		if !isTransientGRPCError(err) || attempt == maxOCRAttempts {
+			return err
+		}
+
+		logger.Debug().Err(err).Int("Attempt", attempt).Msg("... Retrying transient OCR provider error")
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	return err
+}
+
+//---------------------------------------------------------------------------------------
+
+// isTransientGRPCError reports whether err is a gRPC status error worth
+// retrying (Unavailable or ResourceExhausted).
+func isTransientGRPCError(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+//---------------------------------------------------------------------------------------
+
+// writeDetectResults drains results and writes each image's JSON result to sink, invoking
+// written(index, offset, length) for every successfully written result so the caller can
+// checkpoint it. When completionOrder is false (the default) results are resequenced
+// back into input order before writing; otherwise they are written as they arrive. As
+// each result is processed it logs running progress (N/total and images per second).
+// It returns every per-image failure, in the order the results were written (input
+// order unless completionOrder is set).
+func writeDetectResults(files *ImageFiles, sink OutputSink, results <-chan detectResult, completionOrder bool, written func(index int, offset, length int64)) detectErrors {
+	var errs detectErrors
+	total := len(files.Images)
+	completed := 0
+	start := time.Now()
+
+	emit := func(result detectResult) {
+		completed++
+		elapsed := time.Since(start).Seconds()
+		throughput := 0.0
+		if elapsed > 0 {
+			throughput = float64(completed) / elapsed
+		}
+		logger.Info().Int("Completed", completed).Int("Total", total).Float64("ImagesPerSec", throughput).Msg("... Progress")
+
+		if result.err != nil {
+			errs = append(errs, detectError{filename: result.filename, err: result.err})
+			return
+		}
+
+		offset, length, err := sink.Write(&files.Images[result.index], result.jsonData)
+		if err != nil {
+			logger.Error().Err(err).Str("Filename", result.filename).Msg("Failed to write output")
+			errs = append(errs, detectError{filename: result.filename, err: err})
+			return
+		}
+		written(result.index, offset, length)
+	}
+
+	if completionOrder {
+		for result := range results {
+			emit(result)
+		}
+		return errs
+	}
+
+	pending := make(map[int]detectResult)
+	next := 0
+	for result := range results {
+		pending[result.index] = result
+		for {
+			nextResult, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			emit(nextResult)
+			next++
+		}
+	}
+
+	return errs
+}
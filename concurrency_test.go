@@ -0,0 +1,142 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingSink is a minimal OutputSink that just remembers the order results
+// were written in, for asserting on writeDetectResults' resequencing.
+type recordingSink struct {
+	written []int
+}
+
+func (sink *recordingSink) Write(info *ImageInfo, jsonData []byte) (int64, int64, error) {
+	sink.written = append(sink.written, info.Page)
+	return 0, 0, nil
+}
+
+func (sink *recordingSink) SupportsResume() bool { return false }
+func (sink *recordingSink) Close() error         { return nil }
+
+func Test_writeDetectResults(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		completionOrder bool
+		arrival         []int
+		want            []int
+	}{
+		{
+			name:            "input order, out-of-order arrival is resequenced",
+			completionOrder: false,
+			arrival:         []int{2, 0, 1},
+			want:            []int{0, 1, 2},
+		},
+		{
+			name:            "completion order, arrival is passed through unchanged",
+			completionOrder: true,
+			arrival:         []int{2, 0, 1},
+			want:            []int{2, 0, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := &ImageFiles{Images: make([]ImageInfo, 3)}
+			for i := range files.Images {
+				files.Images[i].Page = i
+			}
+
+			sink := &recordingSink{}
+			results := make(chan detectResult, len(tt.arrival))
+			for _, index := range tt.arrival {
+				results <- detectResult{index: index, jsonData: []byte("{}")}
+			}
+			close(results)
+
+			errs := writeDetectResults(files, sink, results, tt.completionOrder, func(index int, offset, length int64) {})
+
+			if len(errs) != 0 {
+				t.Fatalf("writeDetectResults() returned unexpected errors: %v", errs)
+			}
+			if !reflect.DeepEqual(sink.written, tt.want) {
+				t.Errorf("writeDetectResults() wrote pages %v, want %v", sink.written, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeDetectResults_collectsErrors(t *testing.T) {
+
+	files := &ImageFiles{Images: make([]ImageInfo, 2)}
+	sink := &recordingSink{}
+
+	results := make(chan detectResult, 2)
+	results <- detectResult{index: 0, filename: "a.jpg", err: errors.New("boom")}
+	results <- detectResult{index: 1, jsonData: []byte("{}")}
+	close(results)
+
+	errs := writeDetectResults(files, sink, results, true, func(index int, offset, length int64) {})
+
+	if len(errs) != 1 || errs[0].filename != "a.jpg" {
+		t.Errorf("writeDetectResults() errs = %v, want one error for a.jpg", errs)
+	}
+	if !reflect.DeepEqual(sink.written, []int{0}) {
+		t.Errorf("writeDetectResults() wrote pages %v, want only the successful result", sink.written)
+	}
+}
+
+func Test_isTransientGRPCError(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "unavailable is transient",
+			err:  status.Error(codes.Unavailable, "backend unavailable"),
+			want: true,
+		},
+		{
+			name: "resource exhausted is transient",
+			err:  status.Error(codes.ResourceExhausted, "quota exceeded"),
+			want: true,
+		},
+		{
+			name: "invalid argument is not transient",
+			err:  status.Error(codes.InvalidArgument, "bad request"),
+			want: false,
+		},
+		{
+			name: "non-gRPC error is not transient",
+			err:  errors.New("plain error"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientGRPCError(tt.err); got != tt.want {
+				t.Errorf("isTransientGRPCError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
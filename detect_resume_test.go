@@ -0,0 +1,69 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingProvider counts how many times Recognize was called per SourcePath, so
+// tests can assert -resume actually skips reprocessing completed images.
+type countingProvider struct {
+	calls map[string]int
+}
+
+func (p *countingProvider) Recognize(ctx context.Context, info *ImageInfo) error {
+	if p.calls == nil {
+		p.calls = make(map[string]int)
+	}
+	p.calls[info.SourcePath()]++
+	info.Text = "recognized"
+	return nil
+}
+
+// Test_DetectImageText_Resume is an end-to-end regression test for the bug where
+// NewOutputSink truncated the output file before resolveCheckpoint read it back,
+// silently reprocessing every image on every -resume run.
+func Test_DetectImageText_Resume(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(imagePath, []byte("image bytes"), 0644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+	outputFile := filepath.Join(dir, "out.jsonl")
+
+	provider := &countingProvider{}
+	files := &ImageFiles{Images: []ImageInfo{{Filename: imagePath, MimeType: "image/jpeg"}}}
+
+	opts := DetectOptions{OutputFormat: "jsonl", Resume: true}
+
+	if err := files.DetectImageText(outputFile, provider, opts); err != nil {
+		t.Fatalf("First DetectImageText() run failed: %v", err)
+	}
+	if provider.calls[imagePath] != 1 {
+		t.Fatalf("First run called Recognize %d times for %s, want 1", provider.calls[imagePath], imagePath)
+	}
+
+	files = &ImageFiles{Images: []ImageInfo{{Filename: imagePath, MimeType: "image/jpeg"}}}
+	if err := files.DetectImageText(outputFile, provider, opts); err != nil {
+		t.Fatalf("Second DetectImageText() run failed: %v", err)
+	}
+	if provider.calls[imagePath] != 1 {
+		t.Errorf("-resume reprocessed an unchanged image: Recognize called %d times, want 1", provider.calls[imagePath])
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	documentai "cloud.google.com/go/documentai/apiv1"
+	documentaipb "cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+//---------------------------------------------------------------------------------------
+
+// ProcessDocumentAIBatch replaces every PDF/TIFF ImageInfo in files.Images with one
+// ImageInfo per page, by staging the source file to gcsStaging, calling Document AI's
+// asynchronous BatchProcessDocuments, polling the resulting long-running operation,
+// and downloading the sharded JSON output. Use this in place of CallDocumentAI for
+// documents too large for the synchronous ProcessDocument limits.
+func (files *ImageFiles) ProcessDocumentAIBatch(predictionEndpoint string, gcsStaging string) error {
+	ctx := context.Background()
+
+	endpoint, err := url.ParseRequestURI(predictionEndpoint)
+	if err != nil {
+		return err
+	}
+
+	client, err := documentai.NewDocumentProcessorClient(ctx, option.WithEndpoint(GetHostName(endpoint)))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	storageClient, err := storage.NewClient(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to create GCS client: %w", err)
+	}
+	defer storageClient.Close()
+
+	var expanded []ImageInfo
+	for _, info := range files.Images {
+		if info.MimeType != "application/pdf" && info.MimeType != "image/tiff" {
+			expanded = append(expanded, info)
+			continue
+		}
+
+		pages, err := batchProcessImage(ctx, client, storageClient, endpoint, gcsStaging, info)
+		if err != nil {
+			return fmt.Errorf("Batch processing failed for %s: %w", info.Filename, err)
+		}
+
+		expanded = append(expanded, pages...)
+	}
+
+	files.Images = expanded
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// batchProcessImage stages a single document to GCS, runs it through
+// BatchProcessDocuments and returns one ImageInfo per page of the result.
+func batchProcessImage(ctx context.Context, client *documentai.DocumentProcessorClient, storageClient *storage.Client, endpoint *url.URL, gcsStaging string, info ImageInfo) ([]ImageInfo, error) {
+
+	inputURI := fmt.Sprintf("%s/input/%s", strings.TrimSuffix(gcsStaging, "/"), path.Base(info.Filename))
+	outputURI := fmt.Sprintf("%s/output/%s/", strings.TrimSuffix(gcsStaging, "/"), path.Base(info.Filename))
+
+	if err := uploadToGCS(ctx, storageClient, info.SourcePath(), inputURI); err != nil {
+		return nil, fmt.Errorf("Failed to stage input document: %w", err)
+	}
+
+	request := &documentaipb.BatchProcessRequest{
+		Name: GetRequestName(endpoint),
+		InputDocuments: &documentaipb.BatchDocumentsInputConfig{
+			Source: &documentaipb.BatchDocumentsInputConfig_GcsDocuments{
+				GcsDocuments: &documentaipb.GcsDocuments{
+					Documents: []*documentaipb.GcsDocument{
+						{GcsUri: inputURI, MimeType: info.MimeType},
+					},
+				},
+			},
+		},
+		DocumentOutputConfig: &documentaipb.DocumentOutputConfig{
+			Destination: &documentaipb.DocumentOutputConfig_GcsOutputConfig_{
+				GcsOutputConfig: &documentaipb.DocumentOutputConfig_GcsOutputConfig{
+					GcsUri: outputURI,
+				},
+			},
+		},
+	}
+
+	operation, err := client.BatchProcessDocuments(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Poll the long-running operation until the batch completes
+	if _, err := operation.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("Batch process operation failed: %w", err)
+	}
+
+	return downloadBatchOutput(ctx, storageClient, info, outputURI)
+}
+
+//---------------------------------------------------------------------------------------
+
+// downloadBatchOutput fetches every sharded Document JSON object written to
+// outputURI and flattens them into one ImageInfo per page.
+func downloadBatchOutput(ctx context.Context, storageClient *storage.Client, info ImageInfo, outputURI string) ([]ImageInfo, error) {
+	bucket, prefix, err := splitGCSURI(outputURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []ImageInfo
+	it := storageClient.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list batch output shards: %w", err)
+		}
+		if !strings.HasSuffix(attrs.Name, ".json") {
+			continue
+		}
+
+		reader, err := storageClient.Bucket(bucket).Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read batch output shard %s: %w", attrs.Name, err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read batch output shard %s: %w", attrs.Name, err)
+		}
+
+		var document documentaipb.Document
+		if err := protojson.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("Failed to parse batch output shard %s: %w", attrs.Name, err)
+		}
+
+		pages = append(pages, SplitDocumentPages(info, &document)...)
+	}
+
+	return pages, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// uploadToGCS copies a local file to the provided gs:// destination URI.
+func uploadToGCS(ctx context.Context, storageClient *storage.Client, localPath string, gcsURI string) error {
+	bucket, object, err := splitGCSURI(gcsURI)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := storageClient.Bucket(bucket).Object(object).NewWriter(ctx)
+	if _, err := io.Copy(writer, file); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+//---------------------------------------------------------------------------------------
+
+// splitGCSURI splits a "gs://bucket/object/path" URI into its bucket and object parts.
+func splitGCSURI(gcsURI string) (string, string, error) {
+	if !strings.HasPrefix(gcsURI, "gs://") {
+		return "", "", fmt.Errorf("Not a gs:// URI: %s", gcsURI)
+	}
+
+	trimmed := strings.TrimPrefix(gcsURI, "gs://")
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("gs:// URI missing object path: %s", gcsURI)
+	}
+
+	return parts[0], parts[1], nil
+}
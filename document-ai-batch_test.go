@@ -0,0 +1,65 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func Test_splitGCSURI(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		gcsURI     string
+		wantBucket string
+		wantObject string
+		wantErr    bool
+	}{
+		{
+			name:       "bucket and object path",
+			gcsURI:     "gs://my-bucket/inbox/scan1.pdf",
+			wantBucket: "my-bucket",
+			wantObject: "inbox/scan1.pdf",
+		},
+		{
+			name:       "bucket and prefix",
+			gcsURI:     "gs://my-bucket/output/",
+			wantBucket: "my-bucket",
+			wantObject: "output/",
+		},
+		{
+			name:    "not a gs:// URI",
+			gcsURI:  "https://example.com/a.pdf",
+			wantErr: true,
+		},
+		{
+			name:    "missing object path",
+			gcsURI:  "gs://my-bucket",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket, object, err := splitGCSURI(tt.gcsURI)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitGCSURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if bucket != tt.wantBucket || object != tt.wantObject {
+				t.Errorf("splitGCSURI() = (%v, %v), want (%v, %v)", bucket, object, tt.wantBucket, tt.wantObject)
+			}
+		})
+	}
+}
@@ -47,7 +47,7 @@ func (info *ImageInfo) CallDocumentAI(predictionEndpoint string) error {
 	defer client.Close()
 
 	// Open and read the image file
-	image, err := os.ReadFile(info.Filename)
+	image, err := os.ReadFile(info.SourcePath())
 	if err != nil {
 		return err
 	}
@@ -71,6 +71,14 @@ func (info *ImageInfo) CallDocumentAI(predictionEndpoint string) error {
 	info.Text = response.Document.Text
 	logger.Debug().Str("Text", info.Text).Msg("... Document")
 
+	if len(response.Document.Pages) == 1 {
+		info.Page = 1
+	} else if len(response.Document.Pages) > 1 {
+		// Synchronous processing flattens every page's paragraphs into this single
+		// ImageInfo; use -gcs-staging to get one ImageInfo per page instead.
+		logger.Info().Int("Pages", len(response.Document.Pages)).Msg("... Multi-page document, use -gcs-staging for per-page records")
+	}
+
 	for _, page := range response.Document.Pages {
 		for _, paragraph := range page.Paragraphs {
 			textBlock := TextBlock{
@@ -91,6 +99,39 @@ func (info *ImageInfo) CallDocumentAI(predictionEndpoint string) error {
 
 //---------------------------------------------------------------------------------------
 
+// SplitDocumentPages builds one ImageInfo per page of a Document AI response, each
+// carrying only that page's text and paragraphs. base supplies the shared Filename,
+// Size and MimeType. Used by the GCS batch-processing path so large, multi-page PDFs
+// and TIFFs yield per-page records instead of a single flattened one.
+func SplitDocumentPages(base ImageInfo, document *documentaipb.Document) []ImageInfo {
+	pages := make([]ImageInfo, 0, len(document.Pages))
+
+	for i, page := range document.Pages {
+		pageInfo := ImageInfo{
+			Filename: base.Filename,
+			Size:     base.Size,
+			MimeType: base.MimeType,
+			Page:     i + 1,
+			Text:     GetTextFromSegments(page.Layout.TextAnchor.TextSegments, &document.Text),
+		}
+
+		for _, paragraph := range page.Paragraphs {
+			pageInfo.AddParagraph(TextBlock{
+				BoundingBox: GetLayoutBoundingBox(paragraph.Layout.BoundingPoly),
+				Confidence:  paragraph.Layout.Confidence,
+				Orientation: GetLayoutOrientation(paragraph.Layout.BoundingPoly),
+				Text:        GetTextFromSegments(paragraph.Layout.TextAnchor.TextSegments, &document.Text),
+			})
+		}
+
+		pages = append(pages, pageInfo)
+	}
+
+	return pages
+}
+
+//---------------------------------------------------------------------------------------
+
 // Construct the Host Name from the Document AI Prediction Endpoint URL
 func GetHostName(endpoint *url.URL) string {
 
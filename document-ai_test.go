@@ -0,0 +1,87 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	documentaipb "cloud.google.com/go/documentai/apiv1/documentaipb"
+)
+
+func Test_SplitDocumentPages(t *testing.T) {
+
+	base := ImageInfo{Filename: "report.pdf", Size: 1024, MimeType: "application/pdf"}
+
+	document := &documentaipb.Document{
+		Text: "page one text page two text",
+		Pages: []*documentaipb.Document_Page{
+			{
+				Layout: &documentaipb.Document_Page_Layout{
+					TextAnchor: &documentaipb.Document_TextAnchor{
+						TextSegments: []*documentaipb.Document_TextAnchor_TextSegment{
+							{StartIndex: 0, EndIndex: 14},
+						},
+					},
+				},
+				Paragraphs: []*documentaipb.Document_Page_Paragraph{
+					{
+						Layout: &documentaipb.Document_Page_Layout{
+							Confidence: 0.9,
+							TextAnchor: &documentaipb.Document_TextAnchor{
+								TextSegments: []*documentaipb.Document_TextAnchor_TextSegment{
+									{StartIndex: 0, EndIndex: 14},
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Layout: &documentaipb.Document_Page_Layout{
+					TextAnchor: &documentaipb.Document_TextAnchor{
+						TextSegments: []*documentaipb.Document_TextAnchor_TextSegment{
+							{StartIndex: 14, EndIndex: 28},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	pages := SplitDocumentPages(base, document)
+
+	if len(pages) != 2 {
+		t.Fatalf("SplitDocumentPages() returned %d pages, want 2", len(pages))
+	}
+
+	for i, page := range pages {
+		if page.Filename != base.Filename || page.Size != base.Size || page.MimeType != base.MimeType {
+			t.Errorf("page %d did not carry forward base's Filename/Size/MimeType: %+v", i, page)
+		}
+		if page.Page != i+1 {
+			t.Errorf("page %d has Page = %d, want %d", i, page.Page, i+1)
+		}
+	}
+
+	if pages[0].Text != "page one text" {
+		t.Errorf("pages[0].Text = %q, want %q", pages[0].Text, "page one text")
+	}
+	if len(pages[0].Paragraphs) != 1 {
+		t.Errorf("pages[0].Paragraphs = %v, want 1 entry", pages[0].Paragraphs)
+	}
+	if pages[1].Text != "page two text" {
+		t.Errorf("pages[1].Text = %q, want %q", pages[1].Text, "page two text")
+	}
+}
@@ -15,12 +15,17 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"image"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 type TextBlock struct {
@@ -34,8 +39,15 @@ type ImageInfo struct {
 	Filename   string      `json:"filename"`
 	Size       int64       `json:"size"`
 	MimeType   string      `json:"mime_type"`
+	Page       int         `json:"page,omitempty"`
 	Text       string      `json:"text"`
 	Paragraphs []TextBlock `json:"paragraphs"`
+	Words      []TextBlock `json:"words"`
+
+	// sourcePath, when set, is the path an OCR provider should read image bytes
+	// from instead of Filename (e.g. a preprocessed temp file). Filename and
+	// MimeType always describe the original input, not this path.
+	sourcePath string
 }
 
 type ImageFiles struct {
@@ -54,37 +66,72 @@ var mimeTypes = map[string]string{
 	".webp": "image/webp",
 }
 
+// Name of the file, relative to the current working directory, that holds
+// GLOB patterns of files to exclude from processing.
+var ignoreFileName = ".ocr-runnerignore"
+
 //---------------------------------------------------------------------------------------
 
-// Walk the provided input path and populate a list of images in preparation for OCR
+// Walk the provided input path and populate a list of images in preparation for OCR.
+// inputPath may be a doublestar pattern (e.g. "./examples/**/*.jpg") so that a whole
+// directory tree can be matched, not just a single directory level.
 func (files *ImageFiles) PopulateImages(inputPath string) error {
 
-	// Execute a GLOB to return all files matching the provided pattern
-	matches, err := filepath.Glob(inputPath)
-	if err != nil {
-		return fmt.Errorf("Glob Failed: %w", err)
-	}
+	// Split the pattern into the directory to walk and the doublestar pattern to
+	// match entries beneath it against
+	base, pattern := doublestar.SplitPattern(inputPath)
+
+	// Get list of GLOBs to ignore
+	ignoreThis := GetIgnoreList(ignoreFileName)
+
+	err := filepath.WalkDir(base, func(walkPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip Directories
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, walkPath)
+		if err != nil {
+			return fmt.Errorf("Failed to resolve relative path: %w", err)
+		}
+
+		matched, err := doublestar.Match(pattern, filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("Malformed input path pattern: %w", err)
+		}
+		if !matched || IsIgnorableFile(walkPath, ignoreThis) {
+			return nil
+		}
 
-	// Load all matching files returned from the Glob
-	for _, filename := range matches {
-		fileInfo, err := os.Stat(filename)
+		fileInfo, err := entry.Info()
 		if err != nil {
 			return fmt.Errorf("Failed to get file info: %w", err)
 		}
-		mimeType := mimeTypes[filepath.Ext(filename)]
 
-		// Skip Directories and invalid File Extensions
-		if fileInfo.IsDir() || len(mimeType) == 0 {
-			continue
+		mimeType, err := SniffMimeType(walkPath)
+		if err != nil {
+			return fmt.Errorf("Failed to determine MIME type: %w", err)
+		}
+
+		// Skip unsupported File Extensions
+		if len(mimeType) == 0 {
+			return nil
 		}
 
-		image := ImageInfo{
-			Filename: filename,
+		files.Images = append(files.Images, ImageInfo{
+			Filename: walkPath,
 			Size:     fileInfo.Size(),
 			MimeType: mimeType,
-		}
+		})
 
-		files.Images = append(files.Images, image)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Walk Failed: %w", err)
 	}
 
 	return nil
@@ -92,85 +139,69 @@ func (files *ImageFiles) PopulateImages(inputPath string) error {
 
 //---------------------------------------------------------------------------------------
 
-// Iterate through the image file list and call the Vision API to detect the text
-func (files *ImageFiles) DetectImageText(outputFile string, outputFull bool, predictionEndpoint string) error {
+// SniffMimeType determines the MIME type of filename, preferring the content sniffed
+// from its first 512 bytes via http.DetectContentType and falling back to the
+// extension-based mimeTypes map when sniffing is inconclusive. It returns an empty
+// string for files whose extension is not one of the supported image types.
+func SniffMimeType(filename string) (string, error) {
+	extMimeType, supported := mimeTypes[strings.ToLower(filepath.Ext(filename))]
+	if !supported {
+		return "", nil
+	}
 
-	// Create the output file
-	f, err := os.Create(outputFile)
+	file, err := os.Open(filename)
 	if err != nil {
-		return fmt.Errorf("Failed to create output file: %w", err)
+		return "", fmt.Errorf("Failed to open file for MIME sniffing: %w", err)
 	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
-
-	// Execute OCR using Vision API
-	errorCount := 0
-	for i := range files.Images {
-		logger.Info().Msg("Image:")
-		logger.Info().Str("Filename", files.Images[i].Filename).Msg(indent)
-		logger.Info().Int64("Size", files.Images[i].Size).Msg(indent)
-		logger.Info().Str("MimeType", files.Images[i].MimeType).Msg(indent)
-
-		// Call the Vision API if no Document AI Parser Prediction Endpoint is provided
-		if len(predictionEndpoint) == 0 {
-			err := files.Images[i].CallVisionAPI()
-			if err != nil {
-				logger.Error().Err(err).Msg("Vision API request failed")
-				errorCount++
-				continue
-			}
-		} else {
-			err := files.Images[i].CallDocumentAI(predictionEndpoint)
-			if err != nil {
-				logger.Error().Err(err).Msg("Document AI Parser request failed")
-				errorCount++
-				continue
-			}
-		}
-
-		var jsonData []byte
-
-		if outputFull {
-			jsonData, err = files.Images[i].GetFullJSON()
-			if err != nil {
-				logger.Error().Err(err).Msg("Failed to marshal json data")
-				errorCount++
-				continue
-			}
-		} else {
-			jsonData, err = files.Images[i].GetCompactJSON()
-			if err != nil {
-				logger.Error().Err(err).Msg("Failed to marshal json data")
-				errorCount++
-				continue
-			}
-		}
+	defer file.Close()
 
-		// Write out the JSON
-		_, err = w.Write(jsonData)
-		if err != nil {
-			logger.Error().Err(err).Msg("Failed to write to output file")
-			errorCount++
-			continue
-		}
+	buffer := make([]byte, 512)
+	n, err := file.Read(buffer)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("Failed to read file for MIME sniffing: %w", err)
+	}
 
-		// Write out the newline
-		_, err = w.WriteString("\n")
-		if err != nil {
-			logger.Error().Err(err).Msg("Failed to write to output file")
-			errorCount++
-			continue
-		}
+	sniffed := http.DetectContentType(buffer[:n])
+	if idx := strings.Index(sniffed, ";"); idx >= 0 {
+		sniffed = sniffed[:idx]
+	}
 
-		w.Flush()
+	// DetectContentType can't reliably distinguish every supported format (e.g. multi-page
+	// TIFF variants), so fall back to the extension-based type whenever it couldn't
+	// confidently sniff one of our supported image types.
+	if sniffed == "application/octet-stream" || !strings.HasPrefix(sniffed, "image/") && sniffed != "application/pdf" {
+		return extMimeType, nil
 	}
 
-	// Raise an Error if one of the OCR requests failes
-	if errorCount > 0 {
-		return fmt.Errorf("One or more OCR request failed")
+	return sniffed, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// SourcePath returns the path OCR providers should read image bytes from: the
+// preprocessed temp file set by PreprocessConfig.Apply, if any, otherwise Filename.
+func (info *ImageInfo) SourcePath() string {
+	if len(info.sourcePath) > 0 {
+		return info.sourcePath
 	}
+	return info.Filename
+}
 
-	return nil
+//---------------------------------------------------------------------------------------
+
+// CheckpointKey returns the key DetectImageText's checkpoint state should record info's
+// entry under: Filename, with a ".pN" suffix when Page is set. Filename is used rather
+// than SourcePath() because SourcePath may be a temp file (a TIFF frame, a downloaded
+// bucket object or a preprocessed image) whose path differs on every run. Pages split
+// out of the same source (see SplitTIFFFrames, SplitDocumentPages) share Filename, so
+// without the suffix they'd collide on one checkpoint entry and only the last page
+// written would ever be marked complete.
+func (info *ImageInfo) CheckpointKey() string {
+	key := info.Filename
+	if info.Page > 0 {
+		key = fmt.Sprintf("%s.p%d", key, info.Page)
+	}
+	return key
 }
 
 //---------------------------------------------------------------------------------------
@@ -181,11 +212,20 @@ func (info *ImageInfo) AddParagraph(paragraph TextBlock) {
 
 //---------------------------------------------------------------------------------------
 
+func (info *ImageInfo) AddWord(word TextBlock) {
+	info.Words = append(info.Words, word)
+}
+
+//---------------------------------------------------------------------------------------
+
 func (info *ImageInfo) GetCompactJSON() ([]byte, error) {
 
 	compact := make(map[string]interface{})
 	compact["filename"] = info.Filename
 	compact["size"] = info.Size
+	if info.Page > 0 {
+		compact["page"] = info.Page
+	}
 	compact["text"] = info.Text
 
 	paragraphs := make([]map[string]interface{}, len(info.Paragraphs))
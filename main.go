@@ -57,7 +57,24 @@ func main() {
 	var inputPath = flag.String("i", "", "Input Path  (Required)")
 	var outputFile = flag.String("o", "", "Output File  (Required)")
 	var outputFull = flag.Bool("full", false, "Output full details to JSON")
-	var predictionEndpoint = flag.String("endpoint", "", "Document AI Prediction Endpoint  (Optional)")
+	var outputFormat = flag.String("output-format", "jsonl", "Output format: jsonl, gzip, sidecar  (Optional; jsonl also accepts -o - for stdout)")
+	var predictionEndpoint = flag.String("endpoint", "", "Document AI Prediction Endpoint  (Optional, required when -provider=documentai)")
+	var provider = flag.String("provider", "vision", "OCR Provider to use: vision, documentai, tesseract, paddleocr  (Optional)")
+	var tesseractLang = flag.String("tesseract-lang", "", "Tesseract Language(s) to use, e.g. eng  (Optional)")
+	var paddleDetDir = flag.String("paddle-det-dir", "", "PaddleOCR Detection Model Directory  (Required when -provider=paddleocr)")
+	var paddleClsDir = flag.String("paddle-cls-dir", "", "PaddleOCR Classification Model Directory  (Required when -provider=paddleocr)")
+	var paddleRecDir = flag.String("paddle-rec-dir", "", "PaddleOCR Recognition Model Directory  (Required when -provider=paddleocr)")
+	var paddleDictPath = flag.String("paddle-dict", "", "PaddleOCR Character Dictionary Path  (Optional)")
+	var paddleGPU = flag.Bool("paddle-gpu", false, "Run PaddleOCR inference on GPU  (Optional)")
+	var paddleMKLDNN = flag.Bool("paddle-mkldnn", false, "Run PaddleOCR inference with MKL-DNN  (Optional)")
+	var registry = flag.String("registry", "", "Container Registry Repository to push OCI OCR result artifacts to  (Optional)")
+	var concurrency = flag.Int("concurrency", 1, "Number of images to process concurrently  (Optional)")
+	var qps = flag.Float64("qps", 0, "Maximum OCR requests per second, 0 for unlimited  (Optional)")
+	var completionOrder = flag.Bool("completion-order", false, "Write results in completion order instead of input order  (Optional)")
+	var resume = flag.Bool("resume", false, "Resume from the output file's checkpoint state, skipping already completed images  (Optional)")
+	var force = flag.Bool("force", false, "Reprocess every image even if -resume finds a completed checkpoint entry  (Optional)")
+	var gcsStaging = flag.String("gcs-staging", "", "GCS URI, e.g. gs://bucket/prefix, to stage PDF/TIFF documents through Document AI batch mode  (Optional)")
+	var preprocessConfigPath = flag.String("preprocess-config", "", "YAML or JSON file listing image preprocessing steps to run before OCR  (Optional)")
 	var verbose = flag.Bool("verbose", false, "Display verbose or debug detail")
 
 	// Parse the flags
@@ -86,15 +103,44 @@ func main() {
 	logger.Info().Msg("Arguments")
 	logger.Info().Str("Input Path", *inputPath).Msg(indent)
 	logger.Info().Str("Output File", *outputFile).Msg(indent)
+	logger.Info().Str("Output Format", *outputFormat).Msg(indent)
 	logger.Info().Bool("Output Full Details", *outputFull).Msg(indent)
+	logger.Info().Str("Provider", *provider).Msg(indent)
 	logger.Info().Str("Document AI Prediction Endpoint", *predictionEndpoint).Msg(indent)
+	logger.Info().Str("Registry", *registry).Msg(indent)
+	logger.Info().Str("Preprocess Config", *preprocessConfigPath).Msg(indent)
+	logger.Info().Int("Concurrency", *concurrency).Msg(indent)
+	logger.Info().Float64("QPS", *qps).Msg(indent)
 	logger.Info().Msg("Begin")
 
-	// Walk the provided input path and populate a list of images in preparation for OCR
+	// Resolve the configured OCR provider, validating any flags it requires
+	ocrProvider, err := NewOCRProvider(*provider, *predictionEndpoint, *tesseractLang, PaddleOCRConfig{
+		DetModelDir: *paddleDetDir,
+		ClsModelDir: *paddleClsDir,
+		RecModelDir: *paddleRecDir,
+		DictPath:    *paddleDictPath,
+		UseGPU:      *paddleGPU,
+		UseMKLDNN:   *paddleMKLDNN,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to configure OCR provider")
+		os.Exit(1)
+	}
+
+	// Walk the provided input path and populate a list of images in preparation for OCR.
+	// inputPath may instead be a gs://, s3:// or azblob:// bucket URL, in which case its
+	// objects are listed and streamed to local temp files rather than walked on disk.
 	var imageFiles ImageFiles
-	err := imageFiles.PopulateImages(*inputPath)
+	cleanupInput := func() {}
+	if IsBucketURL(*inputPath) {
+		cleanupInput, err = imageFiles.PopulateImagesFromBucket(*inputPath)
+		defer cleanupInput()
+	} else {
+		err = imageFiles.PopulateImages(*inputPath)
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to populate images list from provided input path")
+		cleanupInput()
 		os.Exit(1)
 	}
 
@@ -105,11 +151,70 @@ func main() {
 	}
 	logger.Info().Int("Image Count", len(imageFiles.Images)).Msg("Populating image file list complete")
 
-	// Iterate through the image file list and call the Vision API to detect the text
+	// Expand multi-frame TIFFs into one ImageInfo per frame, unless Document AI batch
+	// mode is staging documents below, which already handles multi-page TIFFs itself
+	cleanupTIFF := func() {}
+	if len(*gcsStaging) == 0 {
+		var err error
+		cleanupTIFF, err = imageFiles.SplitTIFFFrames()
+		defer cleanupTIFF()
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to split multi-frame TIFFs")
+			cleanupTIFF()
+			os.Exit(1)
+		}
+		logger.Info().Int("Image Count", len(imageFiles.Images)).Msg("Splitting multi-frame TIFFs complete")
+	}
+
+	// Stage PDF/TIFF documents through Document AI batch mode, expanding each into one
+	// ImageInfo per page, when a GCS staging location was provided
+	if len(*gcsStaging) > 0 {
+		err = imageFiles.ProcessDocumentAIBatch(*predictionEndpoint, *gcsStaging)
+		if err != nil {
+			logger.Error().Err(err).Msg("Document AI batch processing failed")
+			os.Exit(1)
+		}
+		logger.Info().Int("Image Count", len(imageFiles.Images)).Msg("Document AI batch processing complete")
+	}
+
+	// Run the configured preprocessing pipeline (if any) against every image, pointing
+	// the OCR provider at the transformed bytes while keeping the original Filename
+	// and MimeType in the output
+	cleanupPreprocess := func() {}
+	if len(*preprocessConfigPath) > 0 {
+		preprocessConfig, err := LoadPreprocessConfig(*preprocessConfigPath)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to load preprocess config")
+			os.Exit(1)
+		}
+
+		cleanupPreprocess, err = imageFiles.PreprocessImages(preprocessConfig)
+		defer cleanupPreprocess()
+		if err != nil {
+			logger.Error().Err(err).Msg("Image preprocessing failed")
+			cleanupPreprocess()
+			os.Exit(1)
+		}
+		logger.Info().Msg("Image preprocessing complete")
+	}
+
+	// Iterate through the image file list and call the configured OCR provider to detect the text
 	// Writing out the image information and annotations in JSON format to a file
-	err = imageFiles.DetectImageText(*outputFile, *outputFull, *predictionEndpoint)
+	err = imageFiles.DetectImageText(*outputFile, ocrProvider, DetectOptions{
+		OutputFull:      *outputFull,
+		OutputFormat:    *outputFormat,
+		Registry:        *registry,
+		Concurrency:     *concurrency,
+		QPS:             *qps,
+		CompletionOrder: *completionOrder,
+		Resume:          *resume,
+		Force:           *force,
+	})
 	if err != nil {
 		logger.Error().Err(err).Msg("Image text detection failed")
+		cleanupInput()
+		cleanupTIFF()
+		cleanupPreprocess()
 		os.Exit(1)
 	}
 	logger.Info().Msg("End")
@@ -0,0 +1,138 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Media types used for the OCR result artifact, following the OCI
+// distribution-spec artifact guidance: a small config blob plus a single
+// layer carrying the JSON annotation.
+const (
+	ocrArtifactConfigMediaType = "application/vnd.ocr-runner.config.v1+json"
+	ocrArtifactLayerMediaType  = "application/vnd.ocr-runner.annotation.v1+json"
+)
+
+// ocrArtifactConfig is the small config blob describing the source image
+// that the annotation layer was produced from.
+type ocrArtifactConfig struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mime_type"`
+}
+
+//---------------------------------------------------------------------------------------
+
+// PushOCIArtifact packages the OCR result for a single image as an OCI
+// artifact and pushes it to the provided container registry repository
+// (e.g. "registry.example.com/ocr-results"), tagged by the content-addressable
+// digest of the source image. A subject descriptor pointing at the manifest
+// for the source image digest is attached so downstream tooling can list
+// every OCR run for a given source image via the OCI referrers API.
+func (info *ImageInfo) PushOCIArtifact(registry string, jsonData []byte) error {
+
+	sourceDigest, err := sourceImageDigest(info.SourcePath())
+	if err != nil {
+		return fmt.Errorf("Failed to digest source image: %w", err)
+	}
+
+	repo, err := name.NewRepository(registry)
+	if err != nil {
+		return fmt.Errorf("Invalid registry repository %q: %w", registry, err)
+	}
+
+	configBytes, err := json.Marshal(ocrArtifactConfig{
+		Filename: info.Filename,
+		Size:     info.Size,
+		MimeType: info.MimeType,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to marshal artifact config: %w", err)
+	}
+
+	artifact, err := buildOCIArtifact(configBytes, jsonData)
+	if err != nil {
+		return fmt.Errorf("Failed to build OCI artifact: %w", err)
+	}
+
+	// Reference the base manifest, keyed by the source image digest, that
+	// this OCR run was produced from. The subject is expected to already
+	// exist in the registry (pushed by the user's normal image workflow).
+	subject := v1.Descriptor{
+		MediaType: types.OCIManifestSchema1,
+		Digest:    sourceDigest,
+	}
+	artifact = mutate.Subject(artifact, subject).(v1.Image)
+
+	tag := repo.Tag(sourceDigest.Hex)
+
+	if err := remote.Write(tag, artifact, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		return fmt.Errorf("Failed to push OCI artifact: %w", err)
+	}
+
+	logger.Info().Str("Registry", tag.String()).Msg(indent)
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// buildOCIArtifact assembles an in-memory OCI image consisting of the
+// config and annotation layer blobs described above.
+func buildOCIArtifact(configBytes, layerData []byte) (v1.Image, error) {
+
+	image := mutate.ConfigMediaType(empty.Image, ocrArtifactConfigMediaType)
+	image = mutate.MediaType(image, types.OCIManifestSchema1)
+
+	layer := static.NewLayer(layerData, ocrArtifactLayerMediaType)
+
+	image, err := mutate.AppendLayers(image, layer)
+	if err != nil {
+		return nil, err
+	}
+
+	return mutate.ConfigFile(image, &v1.ConfigFile{
+		Architecture: "unknown",
+		OS:           "unknown",
+	})
+}
+
+//---------------------------------------------------------------------------------------
+
+// sourceImageDigest computes a content-addressable sha256 digest of the
+// source image bytes, used to tag the OCR result artifact.
+func sourceImageDigest(filename string) (v1.Hash, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return v1.Hash{}, err
+	}
+
+	sum := sha256.Sum256(content)
+	return v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])}, nil
+}
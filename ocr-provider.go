@@ -0,0 +1,77 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// OCRProvider performs OCR against a single image, populating its Text,
+// Paragraphs and Words. Implementations are selected at the CLI via the
+// -provider flag, so ImageFiles.DetectImageText never needs to change to
+// support a new backend (Vision API, Document AI, Tesseract, or future
+// providers such as AWS Textract or Azure Read).
+type OCRProvider interface {
+	Recognize(ctx context.Context, info *ImageInfo) error
+}
+
+//---------------------------------------------------------------------------------------
+
+// visionProvider recognises text using the Google Cloud Vision API.
+type visionProvider struct{}
+
+func (p *visionProvider) Recognize(ctx context.Context, info *ImageInfo) error {
+	return info.CallVisionAPI()
+}
+
+//---------------------------------------------------------------------------------------
+
+// documentAIProvider recognises text using a Google Cloud Document AI
+// processor reachable at Endpoint.
+type documentAIProvider struct {
+	Endpoint string
+}
+
+func (p *documentAIProvider) Recognize(ctx context.Context, info *ImageInfo) error {
+	return info.CallDocumentAI(p.Endpoint)
+}
+
+//---------------------------------------------------------------------------------------
+
+// NewOCRProvider constructs the OCRProvider selected by name ("vision",
+// "documentai", "tesseract" or "paddleocr"), validating that the flags required by
+// that provider (e.g. the Document AI endpoint or PaddleOCR model directories) were
+// supplied.
+func NewOCRProvider(provider string, predictionEndpoint string, tesseractLang string, paddleOCR PaddleOCRConfig) (OCRProvider, error) {
+	switch provider {
+	case "", "vision":
+		return &visionProvider{}, nil
+	case "documentai":
+		if len(predictionEndpoint) == 0 {
+			return nil, fmt.Errorf("-endpoint is required when -provider=documentai")
+		}
+		return &documentAIProvider{Endpoint: predictionEndpoint}, nil
+	case "tesseract":
+		return &tesseractProvider{Lang: tesseractLang}, nil
+	case "paddleocr":
+		if len(paddleOCR.DetModelDir) == 0 || len(paddleOCR.ClsModelDir) == 0 || len(paddleOCR.RecModelDir) == 0 {
+			return nil, fmt.Errorf("-paddle-det-dir, -paddle-cls-dir and -paddle-rec-dir are required when -provider=paddleocr")
+		}
+		return &paddleocrProvider{Config: paddleOCR}, nil
+	default:
+		return nil, fmt.Errorf("Unknown OCR provider: %s", provider)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func Test_NewOCRProvider(t *testing.T) {
+
+	type args struct {
+		provider           string
+		predictionEndpoint string
+		paddleOCR          PaddleOCRConfig
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "defaults to vision",
+			args: args{provider: ""},
+		},
+		{
+			name: "vision",
+			args: args{provider: "vision"},
+		},
+		{
+			name:    "documentai requires an endpoint",
+			args:    args{provider: "documentai"},
+			wantErr: true,
+		},
+		{
+			name: "documentai with an endpoint",
+			args: args{provider: "documentai", predictionEndpoint: "https://example.com"},
+		},
+		{
+			name: "tesseract",
+			args: args{provider: "tesseract"},
+		},
+		{
+			name:    "paddleocr requires model directories",
+			args:    args{provider: "paddleocr"},
+			wantErr: true,
+		},
+		{
+			name: "paddleocr with model directories",
+			args: args{provider: "paddleocr", paddleOCR: PaddleOCRConfig{
+				DetModelDir: "det", ClsModelDir: "cls", RecModelDir: "rec",
+			}},
+		},
+		{
+			name:    "unknown provider",
+			args:    args{provider: "unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOCRProvider(tt.args.provider, tt.args.predictionEndpoint, "", tt.args.paddleOCR)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewOCRProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
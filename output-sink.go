@@ -0,0 +1,184 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OutputSink receives each image's OCR result as DetectImageText produces it.
+// Implementations are selected by the -output-format flag: "jsonl" (the default, a
+// single newline-delimited JSON file, or stdout when -o is "-"), "gzip" (the same but
+// gzip-compressed) and "sidecar" (one <image>.json file written into the -o directory
+// per input image).
+type OutputSink interface {
+	// Write records info's JSON result. For sinks backed by a single seekable file
+	// (currently only the plain "jsonl" sink), it returns the byte offset and length
+	// the result was written at so the caller can checkpoint it for -resume; other
+	// sinks return (0, 0).
+	Write(info *ImageInfo, jsonData []byte) (offset int64, length int64, err error)
+	// SupportsResume reports whether Write's returned offsets can be used to resume a
+	// prior run via -resume.
+	SupportsResume() bool
+	Close() error
+}
+
+//---------------------------------------------------------------------------------------
+
+// NewOutputSink constructs the OutputSink selected by format ("jsonl", "gzip" or
+// "sidecar"), creating whatever file or directory it needs at outputFile. outputFile
+// of "-" means stdout, and is only valid for "jsonl" and "gzip".
+func NewOutputSink(outputFile string, format string) (OutputSink, error) {
+	switch format {
+	case "", "jsonl":
+		return newFileOrStdoutSink(outputFile, false, true)
+	case "gzip":
+		return newFileOrStdoutSink(outputFile, true, false)
+	case "sidecar":
+		if outputFile == "-" {
+			return nil, fmt.Errorf("-output-format=sidecar cannot be combined with -o -")
+		}
+		if err := os.MkdirAll(outputFile, 0755); err != nil {
+			return nil, fmt.Errorf("Failed to create sidecar output directory: %w", err)
+		}
+		return &sidecarSink{dir: outputFile}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format: %s", format)
+	}
+}
+
+//---------------------------------------------------------------------------------------
+
+// streamSink writes newline-delimited JSON to a file or stdout, optionally gzipping
+// it, and tracks the byte offset of every line written so resumable sinks can report
+// it back for checkpointing.
+type streamSink struct {
+	writer    *bufio.Writer
+	gzWriter  *gzip.Writer
+	file      *os.File
+	offset    int64
+	resumable bool
+}
+
+func newFileOrStdoutSink(outputFile string, gzipped bool, resumable bool) (*streamSink, error) {
+	if outputFile == "-" {
+		return newStreamSink(os.Stdout, nil, gzipped, false), nil
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create output file: %w", err)
+	}
+
+	return newStreamSink(file, file, gzipped, resumable), nil
+}
+
+func newStreamSink(w io.Writer, file *os.File, gzipped bool, resumable bool) *streamSink {
+	sink := &streamSink{file: file, resumable: resumable}
+
+	target := w
+	if gzipped {
+		sink.gzWriter = gzip.NewWriter(w)
+		target = sink.gzWriter
+	}
+	sink.writer = bufio.NewWriter(target)
+
+	return sink
+}
+
+func (sink *streamSink) Write(info *ImageInfo, jsonData []byte) (int64, int64, error) {
+	offset := sink.offset
+
+	if _, err := sink.writer.Write(jsonData); err != nil {
+		return 0, 0, err
+	}
+	if _, err := sink.writer.WriteString("\n"); err != nil {
+		return 0, 0, err
+	}
+
+	length := int64(len(jsonData))
+	sink.offset += length + 1
+
+	return offset, length, nil
+}
+
+func (sink *streamSink) SupportsResume() bool {
+	return sink.resumable
+}
+
+func (sink *streamSink) Close() error {
+	if err := sink.writer.Flush(); err != nil {
+		return err
+	}
+	if sink.gzWriter != nil {
+		if err := sink.gzWriter.Close(); err != nil {
+			return err
+		}
+	}
+	if sink.file != nil {
+		return sink.file.Close()
+	}
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// sidecarSink writes one <image>.json file per result into dir, rather than
+// appending lines to a single output file.
+type sidecarSink struct {
+	dir string
+}
+
+func (sink *sidecarSink) Write(info *ImageInfo, jsonData []byte) (int64, int64, error) {
+	path := filepath.Join(sink.dir, sidecarFilename(info))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, 0, err
+	}
+	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, nil
+}
+
+func (sink *sidecarSink) SupportsResume() bool {
+	return false
+}
+
+func (sink *sidecarSink) Close() error {
+	return nil
+}
+
+// sidecarFilename derives a sidecar path from info, mirroring info.Filename's
+// directory structure beneath the sidecar output directory (creating it via
+// MkdirAll in Write) so that images sharing a basename in different input
+// subdirectories - routine given PopulateImages' recursive tree walk - don't
+// overwrite each other's .json file. It adds a ".pN" suffix when info.Page is
+// set so multiple pages split out of the same source file (see
+// SplitDocumentPages, SplitTIFFFrames) don't collide either.
+func sidecarFilename(info *ImageInfo) string {
+	rel := filepath.ToSlash(strings.TrimSuffix(info.Filename, filepath.Ext(info.Filename)))
+	rel = strings.TrimPrefix(rel, "/")
+	rel = strings.ReplaceAll(rel, "://", "/")
+	if info.Page > 0 {
+		rel = fmt.Sprintf("%s.p%d", rel, info.Page)
+	}
+	return rel + ".json"
+}
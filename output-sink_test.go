@@ -0,0 +1,58 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func Test_sidecarFilename(t *testing.T) {
+
+	tests := []struct {
+		name string
+		info ImageInfo
+		want string
+	}{
+		{
+			name: "basic",
+			info: ImageInfo{Filename: "scan1.jpg"},
+			want: "scan1.json",
+		},
+		{
+			name: "different subdirectories with the same basename don't collide",
+			info: ImageInfo{Filename: "2023/jan/scan1.jpg"},
+			want: "2023/jan/scan1.json",
+		},
+		{
+			name: "page suffix disambiguates multi-page records",
+			info: ImageInfo{Filename: "2023/jan/scan1.jpg", Page: 2},
+			want: "2023/jan/scan1.p2.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sidecarFilename(&tt.info); got != tt.want {
+				t.Errorf("sidecarFilename() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sidecarFilename_noCollision(t *testing.T) {
+	a := ImageInfo{Filename: "2023/jan/scan1.jpg"}
+	b := ImageInfo{Filename: "2023/feb/scan1.jpg"}
+
+	if got := sidecarFilename(&a); got == sidecarFilename(&b) {
+		t.Errorf("sidecarFilename() collided for %q and %q: both produced %v", a.Filename, b.Filename, got)
+	}
+}
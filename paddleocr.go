@@ -0,0 +1,134 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+)
+
+// PaddleOCRConfig configures the local detector -> classifier -> recognizer pipeline
+// run by paddleocrProvider.
+type PaddleOCRConfig struct {
+	DetModelDir string
+	ClsModelDir string
+	RecModelDir string
+	DictPath    string
+	UseGPU      bool
+	UseMKLDNN   bool
+}
+
+// paddleocrProvider recognises text fully offline by running the PaddleOCR
+// detector -> classifier -> recognizer pipeline via the `paddleocr` CLI.
+type paddleocrProvider struct {
+	Config PaddleOCRConfig
+}
+
+// paddleOCRResult mirrors one entry of `paddleocr --output_format json`: a
+// quadrilateral box, the recognised text and its confidence score.
+type paddleOCRResult struct {
+	Box   [4][2]int `json:"box"`
+	Text  string    `json:"text"`
+	Score float32   `json:"score"`
+}
+
+//---------------------------------------------------------------------------------------
+
+// Recognize runs the configured PaddleOCR pipeline against the image and parses its
+// JSON output into ImageInfo.Paragraphs (PaddleOCR reports one recognised text line
+// per detected box, so each becomes a single-line paragraph).
+func (p *paddleocrProvider) Recognize(ctx context.Context, info *ImageInfo) error {
+
+	args := []string{
+		"--image_dir", info.SourcePath(),
+		"--det_model_dir", p.Config.DetModelDir,
+		"--cls_model_dir", p.Config.ClsModelDir,
+		"--rec_model_dir", p.Config.RecModelDir,
+		"--use_angle_cls", "true",
+		"--use_gpu", strconv.FormatBool(p.Config.UseGPU),
+		"--enable_mkldnn", strconv.FormatBool(p.Config.UseMKLDNN),
+		"--output_format", "json",
+	}
+	if len(p.Config.DictPath) > 0 {
+		args = append(args, "--rec_char_dict_path", p.Config.DictPath)
+	}
+
+	cmd := exec.CommandContext(ctx, "paddleocr", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("paddleocr failed: %w: %s", err, stderr.String())
+	}
+
+	var results []paddleOCRResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		return fmt.Errorf("Failed to parse paddleocr output: %w", err)
+	}
+
+	var text []string
+	for _, result := range results {
+		textBlock := TextBlock{
+			BoundingBox: paddleBoundingRect(result.Box),
+			Confidence:  result.Score,
+			Text:        result.Text,
+		}
+		info.AddParagraph(textBlock)
+		text = append(text, result.Text)
+
+		logger.Debug().Str("Text", textBlock.Text).Float32("Confidence", textBlock.Confidence).Msg("... Paragraph")
+	}
+
+	for i, line := range text {
+		if i > 0 {
+			info.Text += "\n"
+		}
+		info.Text += line
+	}
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// paddleBoundingRect converts a PaddleOCR quadrilateral box (4 [x,y] vertices,
+// not necessarily axis-aligned) into its enclosing axis-aligned rectangle.
+func paddleBoundingRect(box [4][2]int) image.Rectangle {
+	minX, minY := box[0][0], box[0][1]
+	maxX, maxY := box[0][0], box[0][1]
+
+	for _, vertex := range box[1:] {
+		if vertex[0] < minX {
+			minX = vertex[0]
+		}
+		if vertex[0] > maxX {
+			maxX = vertex[0]
+		}
+		if vertex[1] < minY {
+			minY = vertex[1]
+		}
+		if vertex[1] > maxY {
+			maxY = vertex[1]
+		}
+	}
+
+	return image.Rect(minX, minY, maxX, maxY)
+}
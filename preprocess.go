@@ -0,0 +1,182 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"gopkg.in/yaml.v3"
+)
+
+// PreprocessStep is a single operation in a PreprocessConfig pipeline.
+//
+// Op is one of: autoorient, grayscale, resize, lanczos, mitchellnetravali,
+// contrast, brightness, deskew.
+type PreprocessStep struct {
+	Op     string  `json:"op" yaml:"op"`
+	Max    int     `json:"max,omitempty" yaml:"max,omitempty"`
+	Amount float64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+}
+
+// PreprocessConfig is an ordered set of image operations applied to each image prior
+// to OCR, loaded from a YAML or JSON file via the -preprocess-config flag.
+type PreprocessConfig struct {
+	Steps []PreprocessStep `json:"steps" yaml:"steps"`
+}
+
+//---------------------------------------------------------------------------------------
+
+// LoadPreprocessConfig reads a PreprocessConfig from path, decoding it as YAML or
+// JSON based on its file extension.
+func LoadPreprocessConfig(path string) (*PreprocessConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read preprocess config: %w", err)
+	}
+
+	var config PreprocessConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse preprocess config: %w", err)
+	}
+
+	return &config, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// Apply runs the configured pipeline against srcPath, writing the transformed image
+// to a new temp file and returning its path. The caller is responsible for calling
+// the returned cleanup func once it is done with the temp file. If the pipeline has
+// no steps, srcPath is returned unchanged and cleanup is a no-op.
+func (config *PreprocessConfig) Apply(srcPath string) (string, func(), error) {
+	noop := func() {}
+
+	if config == nil || len(config.Steps) == 0 {
+		return srcPath, noop, nil
+	}
+
+	opened, err := imaging.Open(srcPath, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", noop, fmt.Errorf("Failed to open image for preprocessing: %w", err)
+	}
+	img := imaging.Clone(opened)
+
+	for _, step := range config.Steps {
+		img, err = applyPreprocessStep(img, step)
+		if err != nil {
+			return "", noop, fmt.Errorf("Failed to apply preprocess step %q: %w", step.Op, err)
+		}
+	}
+
+	tempFile, err := os.CreateTemp("", "ocr-runner-preprocess-*"+filepath.Ext(srcPath))
+	if err != nil {
+		return "", noop, fmt.Errorf("Failed to create preprocess temp file: %w", err)
+	}
+	tempFile.Close()
+
+	if err := imaging.Save(img, tempFile.Name()); err != nil {
+		os.Remove(tempFile.Name())
+		return "", noop, fmt.Errorf("Failed to save preprocessed image: %w", err)
+	}
+
+	cleanup := func() { os.Remove(tempFile.Name()) }
+
+	return tempFile.Name(), cleanup, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// PreprocessImages runs config's pipeline against every image in files, pointing each
+// ImageInfo's SourcePath at the resulting temp file while leaving its Filename and
+// MimeType untouched. It returns a cleanup func that removes every temp file created;
+// the caller should defer it once, after OCR has finished with the whole batch.
+// Document AI batch page records (Page > 0 with text already populated) are skipped,
+// since they have no local image bytes to preprocess.
+func (files *ImageFiles) PreprocessImages(config *PreprocessConfig) (func(), error) {
+	var cleanups []func()
+	cleanupAll := func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}
+
+	if config == nil || len(config.Steps) == 0 {
+		return cleanupAll, nil
+	}
+
+	for i := range files.Images {
+		info := &files.Images[i]
+		if info.Page > 0 && len(info.Text) > 0 {
+			continue
+		}
+		if info.MimeType == "application/pdf" {
+			continue
+		}
+
+		processedPath, cleanup, err := config.Apply(info.SourcePath())
+		if err != nil {
+			cleanupAll()
+			return func() {}, fmt.Errorf("Failed to preprocess %s: %w", info.Filename, err)
+		}
+		cleanups = append(cleanups, cleanup)
+
+		if processedPath != info.SourcePath() {
+			info.sourcePath = processedPath
+			logger.Debug().Str("Filename", info.Filename).Msg("... Preprocessed")
+		}
+	}
+
+	return cleanupAll, nil
+}
+
+//---------------------------------------------------------------------------------------
+
+func applyPreprocessStep(img *image.NRGBA, step PreprocessStep) (*image.NRGBA, error) {
+	switch step.Op {
+	case "autoorient":
+		// Already applied by imaging.Open(imaging.AutoOrientation(true))
+		return img, nil
+	case "grayscale":
+		return imaging.Grayscale(img), nil
+	case "resize":
+		return imaging.Fit(img, step.Max, step.Max, imaging.Lanczos), nil
+	case "lanczos":
+		return imaging.Fit(img, step.Max, step.Max, imaging.Lanczos), nil
+	case "mitchellnetravali":
+		return imaging.Fit(img, step.Max, step.Max, imaging.MitchellNetravali), nil
+	case "contrast":
+		return imaging.AdjustContrast(img, step.Amount), nil
+	case "brightness":
+		return imaging.AdjustBrightness(img, step.Amount), nil
+	case "deskew":
+		// Automatic skew-angle detection isn't implemented; Amount is the
+		// user-supplied correction angle in degrees.
+		return imaging.Rotate(img, step.Amount, nil), nil
+	default:
+		return nil, fmt.Errorf("Unknown preprocess op: %s", step.Op)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021-2023, Matthew Winter
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// tesseractProvider recognises text fully offline by shelling out to the
+// local Tesseract OCR engine (`tesseract` must be on PATH).
+type tesseractProvider struct {
+	Lang string
+}
+
+//---------------------------------------------------------------------------------------
+
+// Recognize runs Tesseract against the image and parses its TSV output
+// (one row per detected word, with bounding box and confidence) into
+// ImageInfo.Words, grouping words by Tesseract's page/block/paragraph/line
+// number into ImageInfo.Paragraphs (one entry per recognised line).
+func (p *tesseractProvider) Recognize(ctx context.Context, info *ImageInfo) error {
+
+	args := []string{info.SourcePath(), "stdout", "--psm", "3", "tsv"}
+	if len(p.Lang) > 0 {
+		args = append([]string{"-l", p.Lang}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "tesseract", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("tesseract failed: %w: %s", err, stderr.String())
+	}
+
+	text, err := parseTesseractTSV(info, stdout.Bytes())
+	if err != nil {
+		return err
+	}
+	info.Text = text
+
+	return nil
+}
+
+//---------------------------------------------------------------------------------------
+
+// parseTesseractTSV parses the `tesseract ... tsv` output format, appending
+// a TextBlock to ImageInfo.Words for every recognised word and grouping
+// words that share the same block/paragraph/line into ImageInfo.Paragraphs.
+// It returns the concatenated recognised text.
+func parseTesseractTSV(info *ImageInfo, output []byte) (string, error) {
+
+	type line struct {
+		key   string
+		words []string
+	}
+
+	lines := make(map[string]*line)
+	var order []string
+	var fullText []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	header := true
+	for scanner.Scan() {
+		row := scanner.Text()
+		if header {
+			header = false
+			continue
+		}
+		if len(strings.TrimSpace(row)) == 0 {
+			continue
+		}
+
+		fields := strings.Split(row, "\t")
+		if len(fields) < 12 {
+			continue
+		}
+
+		text := strings.TrimSpace(fields[11])
+		if len(text) == 0 {
+			continue
+		}
+
+		left, _ := strconv.Atoi(fields[6])
+		top, _ := strconv.Atoi(fields[7])
+		width, _ := strconv.Atoi(fields[8])
+		height, _ := strconv.Atoi(fields[9])
+		confidence, _ := strconv.ParseFloat(fields[10], 32)
+
+		word := TextBlock{
+			BoundingBox: image.Rect(left, top, left+width, top+height),
+			Confidence:  float32(confidence) / 100,
+			Text:        text,
+		}
+		info.AddWord(word)
+		fullText = append(fullText, text)
+
+		lineKey := strings.Join(fields[1:5], "-")
+		if lines[lineKey] == nil {
+			lines[lineKey] = &line{key: lineKey}
+			order = append(order, lineKey)
+		}
+		lines[lineKey].words = append(lines[lineKey].words, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("Failed to parse tesseract output: %w", err)
+	}
+
+	for _, key := range order {
+		l := lines[key]
+		info.AddParagraph(TextBlock{Text: strings.Join(l.words, " ")})
+	}
+
+	return strings.Join(fullText, " "), nil
+}